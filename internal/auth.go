@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnauthorized is returned when a request cannot be attributed to a user.
+var ErrUnauthorized = errors.New("unauthorized")
+
+// apiKeys maps static API keys to the username they authenticate as.
+// In a real deployment this would be backed by a database or secret store.
+var apiKeys = map[string]string{
+	"test-api-key-alice": "alice",
+	"test-api-key-bob":   "bob",
+}
+
+// jwtSigningKey is the HMAC secret used to verify bearer tokens.
+var jwtSigningKey = []byte("change-me-in-production")
+
+// authenticate derives the calling username from either an `X-API-Key`
+// header or a `Bearer` JWT in the `Authorization` header.
+func authenticate(r *http.Request) (string, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		username, ok := apiKeys[key]
+		if !ok {
+			return "", ErrUnauthorized
+		}
+		return username, nil
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", ErrUnauthorized
+	}
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return jwtSigningKey, nil
+	})
+	if err != nil || !token.Valid {
+		return "", ErrUnauthorized
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", ErrUnauthorized
+	}
+	username, ok := claims["username"].(string)
+	if !ok || username == "" {
+		return "", ErrUnauthorized
+	}
+	return username, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func signedToken(t *testing.T, claims jwt.MapClaims, key []byte) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return s
+}
+
+func TestAuthenticate(t *testing.T) {
+	validToken := signedToken(t, jwt.MapClaims{
+		"username": "alice",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}, jwtSigningKey)
+
+	wrongKeyToken := signedToken(t, jwt.MapClaims{
+		"username": "alice",
+		"exp":      time.Now().Add(time.Hour).Unix(),
+	}, []byte("wrong-key"))
+
+	noUsernameToken := signedToken(t, jwt.MapClaims{
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}, jwtSigningKey)
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantUser string
+		wantErr  bool
+	}{
+		{
+			name:     "valid api key",
+			headers:  map[string]string{"X-API-Key": "test-api-key-alice"},
+			wantUser: "alice",
+		},
+		{
+			name:    "unknown api key",
+			headers: map[string]string{"X-API-Key": "not-a-real-key"},
+			wantErr: true,
+		},
+		{
+			name:     "valid bearer jwt",
+			headers:  map[string]string{"Authorization": "Bearer " + validToken},
+			wantUser: "alice",
+		},
+		{
+			name:    "jwt signed with wrong key",
+			headers: map[string]string{"Authorization": "Bearer " + wrongKeyToken},
+			wantErr: true,
+		},
+		{
+			name:    "jwt missing username claim",
+			headers: map[string]string{"Authorization": "Bearer " + noUsernameToken},
+			wantErr: true,
+		},
+		{
+			name:    "no credentials at all",
+			headers: map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/uploads", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+
+			username, err := authenticate(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && username != tt.wantUser {
+				t.Errorf("authenticate() username = %q, want %q", username, tt.wantUser)
+			}
+		})
+	}
+}
@@ -1,30 +1,25 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"time"
 
 	"cloud.google.com/go/storage"
-	"github.com/google/uuid"
 )
 
-// GenerateUploadPolicy generates a signed POST policy for a specific user/job folder.
-func GenerateUploadPolicy(
-	ctx context.Context, client *storage.Client, bucket, username, jobID string, expireMinutes int,
-) (*storage.PostPolicyV4, string, error) {
-	prefix := fmt.Sprintf("%s/%s/", username, jobID)
-	objectKey := prefix + "${filename}"
+// jobPrefix returns the object-key prefix a user/job pair is scoped to.
+func jobPrefix(username, jobID string) string {
+	return fmt.Sprintf("%s/%s/", username, jobID)
+}
 
-	opts := &storage.PostPolicyV4Options{
+// prefixPolicyOpts builds the PostPolicyV4Options shared by every policy
+// scoped to prefix, before any per-file conditions are layered on top.
+func prefixPolicyOpts(prefix string, expireMinutes int) *storage.PostPolicyV4Options {
+	return &storage.PostPolicyV4Options{
 		Expires: time.Now().Add(time.Duration(expireMinutes) * time.Minute),
 		Fields: &storage.PolicyV4Fields{
 			ContentEncoding: "gzip",
@@ -34,6 +29,41 @@ func GenerateUploadPolicy(
 			storage.ConditionStartsWith("$Content-Encoding", ""),
 		},
 	}
+}
+
+// GenerateUploadPolicy generates a signed POST policy for one file within a
+// user/job folder. The key condition is the same prefix-scoped one every
+// policy uses, so the object name is not pinned; the file is identified by
+// its declared sha256 metadata and bounded by contentLength. The only
+// integrity condition GCS itself
+// enforces here is size: contentLength caps how many bytes it will accept.
+// sha256B64 (the base64 SHA-256 digest of the file's plaintext) is recorded
+// as object metadata so the broker and its clients can recognize which
+// upload a policy was meant for, but GCS does not validate custom metadata
+// against the bytes it receives — the metadata condition below only
+// constrains what the client may *declare*, it can't reject tampered
+// content. GCS's own x-goog-hash enforcement only supports crc32c/md5, not
+// sha256, and would need the hash of the final (gzip'd) bytes known up
+// front, which this streaming upload path doesn't have until the stream is
+// done. Real tamper/corruption detection therefore happens after the fact,
+// in UploadReaderWithPolicy: it recomputes the crc32c of what it actually
+// streamed and verifyUploadedObject compares that against what GCS reports
+// it stored, so corruption is caught once the object exists rather than
+// rejected mid-upload.
+func GenerateUploadPolicy(
+	ctx context.Context, client *storage.Client, bucket, username, jobID, sha256B64 string, contentLength uint64, expireMinutes int,
+) (*storage.PostPolicyV4, string, error) {
+	prefix := jobPrefix(username, jobID)
+	objectKey := prefix + "${filename}"
+
+	opts := prefixPolicyOpts(prefix, expireMinutes)
+	opts.Fields.Metadata = map[string]string{"sha256": sha256B64}
+	opts.Conditions = append(opts.Conditions,
+		storage.ConditionStartsWith("$x-goog-meta-sha256", sha256B64),
+		// The compressed size isn't known until the gzip stream finishes,
+		// so contentLength is an upper bound rather than an exact match.
+		storage.ConditionContentLengthRange(0, contentLength),
+	)
 
 	policy, err := client.Bucket(bucket).GenerateSignedPostPolicyV4(objectKey, opts)
 	if err != nil {
@@ -42,75 +72,27 @@ func GenerateUploadPolicy(
 	return policy, prefix, nil
 }
 
-// CreateGzippedFile creates a text file in memory and gzips it to disk.
-func CreateGzippedFile(filePath, content string) error {
-	var buf bytes.Buffer
-	gz := gzip.NewWriter(&buf)
-	if _, err := gz.Write([]byte(content)); err != nil {
-		return fmt.Errorf("gzip write: %w", err)
-	}
-	if err := gz.Close(); err != nil {
-		return fmt.Errorf("gzip close: %w", err)
-	}
-
-	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
-		return fmt.Errorf("write gz file: %w", err)
-	}
-	return nil
-}
-
-// UploadFileWithPolicy uploads a local file using the generated policy.
-func UploadFileWithPolicy(policy *storage.PostPolicyV4, localFile, objectKey string) error {
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Add policy fields
-	for k, v := range policy.Fields {
-		if err := writer.WriteField(k, v); err != nil {
-			return fmt.Errorf("write field %s: %w", k, err)
-		}
-	}
-
-	// Add file
-	fileWriter, err := writer.CreateFormFile("file", localFile)
-	if err != nil {
-		return fmt.Errorf("create form file: %w", err)
-	}
-
-	file, err := os.Open(localFile)
-	if err != nil {
-		return fmt.Errorf("open file: %w", err)
-	}
-	defer file.Close()
-
-	if _, err := io.Copy(fileWriter, file); err != nil {
-		return fmt.Errorf("copy file: %w", err)
-	}
-
-	writer.Close()
-
-	// POST request
-	req, err := http.NewRequest("POST", policy.URL, &buf)
-	if err != nil {
-		return fmt.Errorf("new request: %w", err)
-	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
+// GenerateUploadPolicyForPrefix generates a signed POST policy scoped to an
+// entire user/job prefix without any per-file sha256 or size conditions, for batch operations
+// like UploadTree where a single policy must cover many objects that land
+// under the same prefix.
+func GenerateUploadPolicyForPrefix(
+	ctx context.Context, client *storage.Client, bucket, username, jobID string, expireMinutes int,
+) (*storage.PostPolicyV4, string, error) {
+	prefix := jobPrefix(username, jobID)
+	objectKey := prefix + "${filename}"
 
-	resp, err := http.DefaultClient.Do(req)
+	policy, err := client.Bucket(bucket).GenerateSignedPostPolicyV4(objectKey, prefixPolicyOpts(prefix, expireMinutes))
 	if err != nil {
-		return fmt.Errorf("upload error: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("upload failed: status %s, body: %s", resp.Status, string(body))
+		return nil, "", fmt.Errorf("GenerateSignedPostPolicyV4: %w", err)
 	}
-
-	fmt.Printf("✅ Upload succeeded! Object key: %s\n", objectKey)
-	return nil
+	return policy, prefix, nil
 }
 
+// main starts the signed-upload broker: an HTTP service that authenticates
+// callers, mints per-job signed POST policies, and records completed jobs so
+// browsers or CLI tools can upload directly to GCS without proxying bytes
+// through this process.
 func main() {
 	ctx := context.Background()
 	client, err := storage.NewClient(ctx)
@@ -120,30 +102,18 @@ func main() {
 	defer client.Close()
 
 	bucket := os.Getenv("GCS_BUCKET")
-	username := "alice"
-	jobID := uuid.New().String()
-	localFile := "/tmp/test.gz"
-	objectName := "test.gz" // file name in the bucket
-
-	// 1️⃣ Generate signed POST policy
-	policy, prefix, err := GenerateUploadPolicy(ctx, client, bucket, username, jobID, 15) // expires 15min
-	if err != nil {
-		log.Fatalf("GenerateUploadPolicy: %v", err)
+	if bucket == "" {
+		log.Fatal("GCS_BUCKET must be set")
 	}
 
-	// Optional: print policy JSON
-	policyJSON, _ := json.MarshalIndent(policy, "", "  ")
-	fmt.Println("Generated POST policy:", string(policyJSON))
-
-	// 2️⃣ Create gzipped file
-	content := "Hello world! This is a test file for GCS upload.\nLine 2 of the file."
-	if err := CreateGzippedFile(localFile, content); err != nil {
-		log.Fatalf("CreateGzippedFile: %v", err)
+	addr := os.Getenv("ADDR")
+	if addr == "" {
+		addr = ":8080"
 	}
 
-	// 3️⃣ Upload file using policy
-	objectKey := fmt.Sprintf("%s%s", prefix, objectName)
-	if err := UploadFileWithPolicy(policy, localFile, objectKey); err != nil {
-		log.Fatalf("UploadFileWithPolicy: %v", err)
+	srv := newUploadServer(client, bucket)
+	log.Printf("listening on %s (bucket=%s)", addr, bucket)
+	if err := http.ListenAndServe(addr, srv.routes()); err != nil {
+		log.Fatalf("ListenAndServe: %v", err)
 	}
 }
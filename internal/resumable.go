@@ -0,0 +1,99 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// resumableChunkSize is tuned for large-file resumable uploads: bigger
+// chunks mean fewer round trips, at the cost of a bigger resend window if a
+// chunk fails partway through.
+const resumableChunkSize = 16 * 1024 * 1024 // 16MiB
+
+// UploadFileResumable uploads localFile to bucket/objectKey using a GCS
+// resumable Writer instead of a signed POST policy. Gzip compression is
+// streamed through the writer as it writes, and GCS tracks upload progress
+// server-side, so a dropped connection resumes instead of restarting the
+// whole file. Unlike the POST-policy path, this requires direct,
+// credentialed access to client, so it's meant for trusted server-side jobs
+// rather than browser clients.
+func UploadFileResumable(ctx context.Context, client *storage.Client, bucket, objectKey, localFile string) error {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	// Closing the writer finalizes the object, so an abort must cancel its
+	// context instead — Close() on a partial stream would commit a
+	// truncated, corrupt object to the bucket.
+	writerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := client.Bucket(bucket).Object(objectKey).NewWriter(writerCtx)
+	w.ChunkSize = resumableChunkSize
+	w.ContentEncoding = "gzip"
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, file); err != nil {
+		cancel()
+		return fmt.Errorf("copy file: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		cancel()
+		return fmt.Errorf("gzip close: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close writer: %w", err)
+	}
+
+	fmt.Printf("✅ Upload succeeded! Object key: %s\n", objectKey)
+	return nil
+}
+
+// UploadStrategy selects how an Uploader sends file bytes to GCS.
+type UploadStrategy int
+
+const (
+	// UploadStrategyPostPolicy signs a short-lived POST policy and lets the
+	// caller (often a browser) upload directly; use it for untrusted clients.
+	UploadStrategyPostPolicy UploadStrategy = iota
+	// UploadStrategyResumable streams through a GCS resumable Writer from
+	// this process; use it for trusted server-side jobs where resuming a
+	// dropped large-file upload matters more than a shareable URL.
+	UploadStrategyResumable
+)
+
+// Uploader uploads files to a single bucket using a chosen UploadStrategy.
+type Uploader struct {
+	Client   *storage.Client
+	Bucket   string
+	Strategy UploadStrategy
+}
+
+// NewUploader creates an Uploader for bucket using the given strategy.
+func NewUploader(client *storage.Client, bucket string, strategy UploadStrategy) *Uploader {
+	return &Uploader{Client: client, Bucket: bucket, Strategy: strategy}
+}
+
+// Upload sends localFile to objectKey using u.Strategy. policy and
+// expectedSHA256 are only used by UploadStrategyPostPolicy; pass nil and ""
+// respectively for UploadStrategyResumable.
+func (u *Uploader) Upload(ctx context.Context, policy *storage.PostPolicyV4, localFile, objectKey, expectedSHA256 string) error {
+	switch u.Strategy {
+	case UploadStrategyResumable:
+		return UploadFileResumable(ctx, u.Client, u.Bucket, objectKey, localFile)
+	case UploadStrategyPostPolicy:
+		if policy == nil {
+			return fmt.Errorf("UploadStrategyPostPolicy requires a policy")
+		}
+		return UploadFileWithPolicy(ctx, u.Client, u.Bucket, policy, localFile, objectKey, expectedSHA256)
+	default:
+		return fmt.Errorf("unknown upload strategy: %v", u.Strategy)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestUploaderUpload_InvalidInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy UploadStrategy
+		policy   *storage.PostPolicyV4
+		wantErr  bool
+	}{
+		{
+			name:     "post policy strategy without a policy",
+			strategy: UploadStrategyPostPolicy,
+			policy:   nil,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown strategy",
+			strategy: UploadStrategy(99),
+			policy:   nil,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &Uploader{Strategy: tt.strategy}
+			err := u.Upload(context.Background(), tt.policy, "local-file", "object-key", "sha256")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Upload() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+)
+
+// defaultPolicyExpireMinutes is how long a generated signed POST policy stays valid.
+const defaultPolicyExpireMinutes = 15
+
+// jobMetadata records what we know about an upload job once it completes.
+type jobMetadata struct {
+	Username  string    `json:"username"`
+	JobID     string    `json:"jobID"`
+	Prefix    string    `json:"prefix"`
+	Objects   []string  `json:"objects"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// uploadServer wires the signed-policy generator to HTTP handlers and keeps
+// an in-memory record of completed jobs.
+type uploadServer struct {
+	client *storage.Client
+	bucket string
+
+	mu   sync.Mutex
+	jobs map[string]jobMetadata
+}
+
+func newUploadServer(client *storage.Client, bucket string) *uploadServer {
+	return &uploadServer{
+		client: client,
+		bucket: bucket,
+		jobs:   make(map[string]jobMetadata),
+	}
+}
+
+// createUploadRequest is the body of POST /uploads: the caller must already
+// know the exact file it intends to send so the policy can be pinned to it.
+type createUploadRequest struct {
+	SHA256        string `json:"sha256"`
+	ContentLength int64  `json:"contentLength"`
+}
+
+// createUploadResponse is the payload returned by POST /uploads.
+type createUploadResponse struct {
+	JobID  string                `json:"jobID"`
+	Prefix string                `json:"prefix"`
+	Policy *storage.PostPolicyV4 `json:"policy"`
+}
+
+func (s *uploadServer) handleCreateUpload(w http.ResponseWriter, r *http.Request) {
+	username, err := authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req createUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.SHA256 == "" || req.ContentLength <= 0 {
+		http.Error(w, "sha256 and contentLength are required", http.StatusBadRequest)
+		return
+	}
+
+	jobID := uuid.New().String()
+	policy, prefix, err := GenerateUploadPolicy(r.Context(), s.client, s.bucket, username, jobID, req.SHA256, uint64(req.ContentLength), defaultPolicyExpireMinutes)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("GenerateUploadPolicy: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, createUploadResponse{
+		JobID:  jobID,
+		Prefix: prefix,
+		Policy: policy,
+	})
+}
+
+// completeUploadResponse is the payload returned by POST /uploads/{jobID}/complete.
+type completeUploadResponse struct {
+	JobID   string   `json:"jobID"`
+	Objects []string `json:"objects"`
+}
+
+func (s *uploadServer) handleCompleteUpload(w http.ResponseWriter, r *http.Request) {
+	username, err := authenticate(r)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	jobID := jobIDFromPath(r.URL.Path)
+	if jobID == "" {
+		http.Error(w, "missing jobID", http.StatusBadRequest)
+		return
+	}
+
+	prefix := jobPrefix(username, jobID)
+	objects, err := s.listObjects(r.Context(), prefix)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("listObjects: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.jobs[jobID] = jobMetadata{
+		Username:  username,
+		JobID:     jobID,
+		Prefix:    prefix,
+		Objects:   objects,
+		CreatedAt: time.Now(),
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, completeUploadResponse{JobID: jobID, Objects: objects})
+}
+
+// listObjects returns the names of every object stored under prefix.
+func (s *uploadServer) listObjects(ctx context.Context, prefix string) ([]string, error) {
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	var names []string
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, attrs.Name)
+	}
+	return names, nil
+}
+
+// jobIDFromPath extracts {jobID} out of a path shaped like /uploads/{jobID}/complete.
+func jobIDFromPath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "uploads" || parts[2] != "complete" {
+		return ""
+	}
+	return parts[1]
+}
+
+func (s *uploadServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/uploads", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.handleCreateUpload(w, r)
+	})
+	mux.HandleFunc("/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/complete") {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		s.handleCompleteUpload(w, r)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestJobIDFromPath(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{name: "well-formed path", path: "/uploads/job-123/complete", want: "job-123"},
+		{name: "well-formed path without leading slash", path: "uploads/job-123/complete", want: "job-123"},
+		{name: "missing complete suffix", path: "/uploads/job-123", want: ""},
+		{name: "wrong resource", path: "/downloads/job-123/complete", want: ""},
+		{name: "extra path segment", path: "/uploads/job-123/extra/complete", want: ""},
+		{name: "empty path", path: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobIDFromPath(tt.path); got != tt.want {
+				t.Errorf("jobIDFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// crc32cTable is the Castagnoli polynomial GCS uses for its crc32c object
+// checksums.
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// UploadOptions configures how UploadReaderWithPolicy compresses and streams
+// data.
+type UploadOptions struct {
+	// CompressionLevel is passed to gzip.NewWriterLevel. Zero means
+	// gzip.DefaultCompression.
+	CompressionLevel int
+}
+
+// uploadHTTPError carries the status of a failed POST to the signed URL so
+// callers such as UploadTree's retry loop can tell a transient server error
+// (5xx) apart from a permanent rejection (4xx).
+type uploadHTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *uploadHTTPError) Error() string {
+	return fmt.Sprintf("upload failed: status %s, body: %s", e.Status, e.Body)
+}
+
+// computeFileSHA256 returns the base64 SHA-256 digest of a file's contents.
+func computeFileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open file: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash file: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// CreateGzippedFile gzips content straight to filePath, never holding the
+// compressed bytes in memory.
+func CreateGzippedFile(filePath, content string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		return fmt.Errorf("gzip write: %w", err)
+	}
+	return gz.Close()
+}
+
+// UploadReaderWithPolicy streams r through an on-the-fly gzip compressor and
+// a multipart writer directly into the policy's signed POST URL via
+// io.Pipe, so inputs of any size never get buffered in memory. r can be a
+// file, stdin, a network socket, or a tar stream. expectedSHA256 is the
+// base64 digest of r's uncompressed bytes; it's recomputed while streaming
+// and checked once the upload completes.
+func UploadReaderWithPolicy(
+	ctx context.Context, client *storage.Client, bucket string, policy *storage.PostPolicyV4,
+	name string, r io.Reader, objectKey, expectedSHA256 string, opts UploadOptions,
+) error {
+	level := opts.CompressionLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	hasher := sha256.New()
+	crcHasher := crc32.New(crc32cTable)
+
+	go func() {
+		defer pw.Close()
+
+		for k, v := range policy.Fields {
+			if k == "key" {
+				// The policy's key template is "prefix${filename}", meant to
+				// be resolved by GCS substituting the multipart file part's
+				// filename. That's unreliable for nested paths, so we send
+				// the fully resolved objectKey explicitly instead and let it
+				// win over the template value.
+				continue
+			}
+			if err := writer.WriteField(k, v); err != nil {
+				pw.CloseWithError(fmt.Errorf("write field %s: %w", k, err))
+				return
+			}
+		}
+		if err := writer.WriteField("key", objectKey); err != nil {
+			pw.CloseWithError(fmt.Errorf("write field key: %w", err))
+			return
+		}
+
+		fileWriter, err := writer.CreateFormFile("file", name)
+		if err != nil {
+			pw.CloseWithError(fmt.Errorf("create form file: %w", err))
+			return
+		}
+
+		// crcHasher observes exactly the compressed bytes written to the
+		// wire, i.e. what GCS will store, so it can be compared against the
+		// object's server-reported crc32c after upload.
+		gz, err := gzip.NewWriterLevel(io.MultiWriter(fileWriter, crcHasher), level)
+		if err != nil {
+			pw.CloseWithError(fmt.Errorf("new gzip writer: %w", err))
+			return
+		}
+
+		if _, err := io.Copy(io.MultiWriter(gz, hasher), r); err != nil {
+			pw.CloseWithError(fmt.Errorf("copy: %w", err))
+			return
+		}
+		if err := gz.Close(); err != nil {
+			pw.CloseWithError(fmt.Errorf("gzip close: %w", err))
+			return
+		}
+		if err := writer.Close(); err != nil {
+			pw.CloseWithError(fmt.Errorf("multipart close: %w", err))
+			return
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, policy.URL, pr)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.TransferEncoding = []string{"chunked"}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return &uploadHTTPError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(body)}
+	}
+
+	actualSHA256 := base64.StdEncoding.EncodeToString(hasher.Sum(nil))
+	if actualSHA256 != expectedSHA256 {
+		return fmt.Errorf("sha256 mismatch: source changed while streaming (expected %s, got %s)", expectedSHA256, actualSHA256)
+	}
+
+	if err := verifyUploadedObject(ctx, client, bucket, objectKey, crcHasher.Sum32()); err != nil {
+		return fmt.Errorf("verify uploaded object: %w", err)
+	}
+
+	fmt.Printf("✅ Upload succeeded! Object key: %s\n", objectKey)
+	return nil
+}
+
+// UploadFileWithPolicy uploads a local file using the generated policy,
+// gzip-compressing it on the fly so the whole file never has to fit in
+// memory. See UploadReaderWithPolicy for the streaming details.
+func UploadFileWithPolicy(ctx context.Context, client *storage.Client, bucket string, policy *storage.PostPolicyV4, localFile, objectKey, expectedSHA256 string) error {
+	file, err := os.Open(localFile)
+	if err != nil {
+		return fmt.Errorf("open file: %w", err)
+	}
+	defer file.Close()
+
+	return UploadReaderWithPolicy(ctx, client, bucket, policy, localFile, file, objectKey, expectedSHA256, UploadOptions{})
+}
+
+// verifyUploadedObject re-reads the object's attrs and compares GCS's
+// server-computed crc32c against expectedCRC32C (the crc32c of the exact
+// bytes this process streamed), returning an error if they don't match so a
+// corrupted or incomplete upload is actually caught rather than merely
+// logged.
+func verifyUploadedObject(ctx context.Context, client *storage.Client, bucket, objectKey string, expectedCRC32C uint32) error {
+	attrs, err := client.Bucket(bucket).Object(objectKey).Attrs(ctx)
+	if err != nil {
+		return fmt.Errorf("read object attrs: %w", err)
+	}
+	if attrs.CRC32C != expectedCRC32C {
+		return fmt.Errorf("crc32c mismatch: GCS stored %d, expected %d", attrs.CRC32C, expectedCRC32C)
+	}
+	log.Printf("object %s verified: crc32c=%d md5Hash=%x", objectKey, attrs.CRC32C, attrs.MD5)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxUploadRetries bounds the total upload attempts for a single file (the
+// first try plus retries on a transient server error); retryBaseDelay is the
+// initial backoff, doubled each attempt.
+const (
+	maxUploadRetries = 3
+	retryBaseDelay   = 500 * time.Millisecond
+)
+
+// FileUploadResult records the outcome of uploading one file from
+// UploadTree, so callers can retry only the files that failed.
+type FileUploadResult struct {
+	Path      string
+	ObjectKey string
+	Size      int64
+	SHA256    string
+	Err       error
+}
+
+// UploadTree walks rootDir and uploads every regular file under it through
+// policy, a single prefix-scoped policy shared by the whole tree (see
+// GenerateUploadPolicyForPrefix). Up to concurrency files are in flight at
+// once, via an errgroup-bounded worker pool. Each file's result is reported
+// independently, including its error if any, so a failed upload never stops
+// the rest of the tree and callers can resubmit just the failed subset.
+func UploadTree(ctx context.Context, client *storage.Client, bucket string, policy *storage.PostPolicyV4, prefix, rootDir string, concurrency int) ([]FileUploadResult, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	var paths []string
+	if err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("walk %s: %w", rootDir, err)
+	}
+
+	results := make([]FileUploadResult, len(paths))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+
+	for i, path := range paths {
+		i, path := i, path
+		g.Go(func() error {
+			rel, err := filepath.Rel(rootDir, path)
+			if err != nil {
+				results[i] = FileUploadResult{Path: path, Err: fmt.Errorf("rel path: %w", err)}
+				return nil
+			}
+			objectKey := prefix + filepath.ToSlash(rel)
+			results[i] = uploadTreeFileWithRetry(gctx, client, bucket, policy, path, objectKey)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// uploadTreeFileWithRetry uploads a single file, retrying with exponential
+// backoff on a transient 5xx response from GCS.
+func uploadTreeFileWithRetry(ctx context.Context, client *storage.Client, bucket string, policy *storage.PostPolicyV4, path, objectKey string) FileUploadResult {
+	info, err := os.Stat(path)
+	if err != nil {
+		return FileUploadResult{Path: path, ObjectKey: objectKey, Err: fmt.Errorf("stat: %w", err)}
+	}
+
+	sha, err := computeFileSHA256(path)
+	if err != nil {
+		return FileUploadResult{Path: path, ObjectKey: objectKey, Size: info.Size(), Err: err}
+	}
+
+	var uploadErr error
+	for attempt := 0; attempt < maxUploadRetries; attempt++ {
+		if attempt > 0 {
+			delay := retryBaseDelay * time.Duration(uint(1)<<uint(attempt-1))
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return FileUploadResult{Path: path, ObjectKey: objectKey, Size: info.Size(), SHA256: sha, Err: ctx.Err()}
+			}
+		}
+
+		uploadErr = UploadFileWithPolicy(ctx, client, bucket, policy, path, objectKey, sha)
+		if uploadErr == nil || !isRetryableUploadError(uploadErr) {
+			break
+		}
+	}
+
+	return FileUploadResult{Path: path, ObjectKey: objectKey, Size: info.Size(), SHA256: sha, Err: uploadErr}
+}
+
+// isRetryableUploadError reports whether err represents a transient server
+// error worth retrying, as opposed to a permanent rejection.
+func isRetryableUploadError(err error) bool {
+	var httpErr *uploadHTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+	return false
+}
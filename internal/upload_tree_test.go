@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestIsRetryableUploadError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "server error is retryable", err: &uploadHTTPError{StatusCode: 503, Status: "503 Service Unavailable"}, want: true},
+		{name: "500 is retryable", err: &uploadHTTPError{StatusCode: 500, Status: "500 Internal Server Error"}, want: true},
+		{name: "client error is not retryable", err: &uploadHTTPError{StatusCode: 403, Status: "403 Forbidden"}, want: false},
+		{name: "wrapped server error is retryable", err: fmt.Errorf("upload: %w", &uploadHTTPError{StatusCode: 502, Status: "502 Bad Gateway"}), want: true},
+		{name: "non-http error is not retryable", err: errors.New("boom"), want: false},
+		{name: "nil error is not retryable", err: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableUploadError(tt.err); got != tt.want {
+				t.Errorf("isRetryableUploadError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}